@@ -4,13 +4,35 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
-	defaultBaseURL = "https://circleci.com/api/v1.1"
-	envvarEndpoint = "envvar"
+	defaultBaseURLV1 = "https://circleci.com/api/v1.1"
+	defaultBaseURLV2 = "https://circleci.com/api/v2"
+	envvarEndpoint   = "envvar"
+
+	graphqlURL = "https://circleci.com/graphql-unstable"
+
+	defaultMaxRetries = 3
+)
+
+// APIVersion selects which CircleCI REST API a Client talks to.
+type APIVersion string
+
+const (
+	// APIVersionV1 is the legacy v1.1 API, authenticated with HTTP Basic
+	// auth and using the org's full VCS name (e.g. "github") in its URLs.
+	// This is the default for backward compatibility.
+	APIVersionV1 APIVersion = "v1.1"
+	// APIVersionV2 is the v2 API, authenticated with a Circle-Token header
+	// and using the short VCS slug (e.g. "gh") in its URLs.
+	APIVersionV2 APIVersion = "v2"
 )
 
 var (
@@ -23,6 +45,42 @@ type EnvironmentVariable struct {
 	Value string `json:"value"`
 }
 
+// Context is a named, org-scoped bucket of environment variables that can be
+// attached to many projects. Contexts are only available through the
+// GraphQL API.
+type Context struct {
+	ID   string
+	Name string
+}
+
+// GraphQLError wraps the messages found in the `errors` array of a GraphQL
+// response. It is returned instead of a plain HTTP status error so callers
+// can tell a GraphQL-level failure (e.g. a context that already exists)
+// apart from a transport or authentication failure.
+type GraphQLError struct {
+	Messages []string
+}
+
+func (e *GraphQLError) Error() string {
+	return fmt.Sprintf("circleci: graphql errors: %s", strings.Join(e.Messages, "; "))
+}
+
+// APIError is returned by the v2 API request handlers (and by
+// GetProjectSettings on a 404 under either API version) when the CircleCI API
+// responds with a non-success status code and a `{"message": "..."}` error
+// body. Callers can use errors.As to distinguish it from a transport-level
+// error, and inspect StatusCode (e.g. http.StatusNotFound) instead of
+// matching on an error string.
+type APIError struct {
+	StatusCode int
+	Message    string
+	Endpoint   string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("circleci: %s: %d %s", e.Endpoint, e.StatusCode, e.Message)
+}
+
 // ClientOpt
 type ClientOpt func(*Client) error
 
@@ -34,6 +92,33 @@ func WithBaseURL(baseURL string) ClientOpt {
 	}
 }
 
+// WithAPIVersion selects which CircleCI API version the client talks to.
+// It also switches the client's base URL to the matching default, unless
+// WithBaseURL is applied afterwards.
+func WithAPIVersion(version APIVersion) ClientOpt {
+	return func(c *Client) error {
+		c.apiVersion = version
+
+		if version == APIVersionV2 {
+			c.baseURL = defaultBaseURLV2
+		} else {
+			c.baseURL = defaultBaseURLV1
+		}
+
+		return nil
+	}
+}
+
+// WithMaxRetries sets how many attempts, the first one included, are made
+// for a request that keeps getting a 429 or a 5xx response before giving up.
+// The default is 3.
+func WithMaxRetries(maxRetries int) ClientOpt {
+	return func(c *Client) error {
+		c.maxRetries = maxRetries
+		return nil
+	}
+}
+
 // ValidateEnvironmentVariableName validates the name of the variable is allowed
 // by CircleCI
 func ValidateEnvironmentVariableName(name string) bool {
@@ -47,16 +132,20 @@ type Client struct {
 	vcsType      string
 	organization string
 	httpClient   *http.Client
+	apiVersion   APIVersion
+	maxRetries   int
 }
 
 // NewClient creates a new CircleCI API client
 func NewClient(token, vcsType, organization string, opts ...ClientOpt) (*Client, error) {
 	client := &Client{
-		baseURL:      defaultBaseURL,
+		baseURL:      defaultBaseURLV1,
 		token:        token,
 		vcsType:      vcsType,
 		organization: organization,
 		httpClient:   http.DefaultClient,
+		apiVersion:   APIVersionV1,
+		maxRetries:   defaultMaxRetries,
 	}
 
 	// Applies all the optional options
@@ -68,7 +157,112 @@ func NewClient(token, vcsType, organization string, opts ...ClientOpt) (*Client,
 }
 
 func (c *Client) buildApiURL(projectName, endpoint string) string {
-	return fmt.Sprintf("%s/project/%s/%s/%s/%s", c.baseURL, c.vcsType, c.organization, projectName, endpoint)
+	vcsType := c.vcsType
+	if c.apiVersion == APIVersionV2 {
+		vcsType = vcsSlug(vcsType)
+	}
+
+	return fmt.Sprintf("%s/project/%s/%s/%s/%s", c.baseURL, vcsType, c.organization, projectName, endpoint)
+}
+
+// vcsSlug maps a v1.1-style VCS name to the short slug used by the v2 API.
+func vcsSlug(vcsType string) string {
+	switch vcsType {
+	case "github":
+		return "gh"
+	case "bitbucket":
+		return "bb"
+	default:
+		return vcsType
+	}
+}
+
+// setAuth sets the authentication header matching the client's API version:
+// HTTP Basic auth for v1.1, and the Circle-Token header for v2.
+func (c *Client) setAuth(req *http.Request) {
+	if c.apiVersion == APIVersionV2 {
+		req.Header.Set("Circle-Token", c.token)
+		return
+	}
+
+	req.SetBasicAuth(c.token, "")
+}
+
+// apiError decodes a `{"message": "..."}` error body into an APIError. It is
+// used for the v2 API, which is documented to return that shape on failure,
+// and for a 404 from GetProjectSettings on either API version.
+func (c *Client) apiError(resp *http.Response, endpoint string) error {
+	var body struct {
+		Message string `json:"message"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    body.Message,
+		Endpoint:   endpoint,
+	}
+}
+
+// doRequest executes req, retrying on 429 (honoring Retry-After) and on 5xx
+// responses with an exponential backoff, up to c.maxRetries attempts.
+func (c *Client) doRequest(req *http.Request) (*http.Response, error) {
+	var bodyBytes []byte
+	if req.Body != nil {
+		var err error
+		bodyBytes, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		if bodyBytes != nil {
+			req.Body = ioutil.NopCloser(bytes.NewReader(bodyBytes))
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if !retryable || attempt+1 >= c.maxRetries {
+			return resp, nil
+		}
+
+		wait := backoffDuration(attempt)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				wait = retryAfter
+			}
+		}
+		resp.Body.Close()
+
+		time.Sleep(wait)
+	}
+}
+
+// backoffDuration returns the exponential backoff delay for a given (zero
+// indexed) retry attempt: 1s, 2s, 4s, ...
+func backoffDuration(attempt int) time.Duration {
+	return time.Duration(1<<uint(attempt)) * time.Second
+}
+
+// parseRetryAfter parses a Retry-After header expressed as a number of
+// seconds, as CircleCI's API does.
+func parseRetryAfter(header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+
+	seconds, err := strconv.Atoi(header)
+	if err != nil {
+		return 0, false
+	}
+
+	return time.Duration(seconds) * time.Second, true
 }
 
 // AddEnvironmentVariable creates a new environment variable.
@@ -95,9 +289,9 @@ func (c *Client) AddEnvironmentVariable(projectName, envName, envValue string) e
 
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
-	req.SetBasicAuth(c.token, "")
+	c.setAuth(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.doRequest(req)
 	if err != nil {
 		// TODO(matteo): proper error handling
 		return err
@@ -105,6 +299,9 @@ func (c *Client) AddEnvironmentVariable(projectName, envName, envValue string) e
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated {
+		if c.apiVersion == APIVersionV2 {
+			return c.apiError(resp, endpointURL)
+		}
 		return fmt.Errorf("client: create wrong status code %d", resp.StatusCode)
 	}
 
@@ -120,9 +317,9 @@ func (c *Client) EnvironmentVariableExists(projectName, envName string) (bool, e
 		return false, err
 	}
 
-	req.SetBasicAuth(c.token, "")
+	c.setAuth(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.doRequest(req)
 	if err != nil {
 		// TODO(matteo): proper error handling
 		return false, err
@@ -134,6 +331,9 @@ func (c *Client) EnvironmentVariableExists(projectName, envName string) (bool, e
 			return false, nil
 		}
 
+		if c.apiVersion == APIVersionV2 {
+			return false, c.apiError(resp, endpointURL)
+		}
 		return false, fmt.Errorf("circleci: wrong status code %d getting environment variable", resp.StatusCode)
 	}
 
@@ -153,9 +353,9 @@ func (c *Client) GetEnvironmentVariable(projectName, envName string) (*Environme
 	}
 
 	req.Header.Set("Accept", "application/json")
-	req.SetBasicAuth(c.token, "")
+	c.setAuth(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.doRequest(req)
 	if err != nil {
 		// TODO(matteo): proper error handling
 		return nil, err
@@ -163,6 +363,9 @@ func (c *Client) GetEnvironmentVariable(projectName, envName string) (*Environme
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		if c.apiVersion == APIVersionV2 {
+			return nil, c.apiError(resp, endpointURL)
+		}
 		return nil, fmt.Errorf("circleci: wrong status code %d getting environment variable", resp.StatusCode)
 	}
 
@@ -188,9 +391,9 @@ func (c *Client) DeleteEnvironmentVariable(projectName, envName string) error {
 	}
 
 	req.Header.Set("Accept", "application/json")
-	req.SetBasicAuth(c.token, "")
+	c.setAuth(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.doRequest(req)
 	if err != nil {
 		// TODO(matteo): proper error handling
 		return err
@@ -198,8 +401,424 @@ func (c *Client) DeleteEnvironmentVariable(projectName, envName string) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		if c.apiVersion == APIVersionV2 {
+			return c.apiError(resp, endpointURL)
+		}
 		return fmt.Errorf("circleci: wrong status code %d deleting environment variable", resp.StatusCode)
 	}
 
 	return nil
 }
+
+// ListEnvironmentVariables returns every environment variable of a project,
+// following the v2 API's next_page_token cursor until it is exhausted. It
+// requires a client configured with WithAPIVersion(APIVersionV2): the v1.1
+// API has no paginated listing endpoint with this response shape.
+func (c *Client) ListEnvironmentVariables(projectName string) ([]EnvironmentVariable, error) {
+	if c.apiVersion != APIVersionV2 {
+		return nil, fmt.Errorf("circleci: ListEnvironmentVariables requires a client configured with WithAPIVersion(APIVersionV2)")
+	}
+
+	var envVars []EnvironmentVariable
+	pageToken := ""
+
+	for {
+		endpointURL := c.buildApiURL(projectName, envvarEndpoint)
+		if pageToken != "" {
+			endpointURL = fmt.Sprintf("%s?page-token=%s", endpointURL, pageToken)
+		}
+
+		req, err := http.NewRequest(http.MethodGet, endpointURL, nil)
+		if err != nil {
+			// TODO(matteo): proper error handling
+			return nil, err
+		}
+
+		req.Header.Set("Accept", "application/json")
+		c.setAuth(req)
+
+		resp, err := c.doRequest(req)
+		if err != nil {
+			// TODO(matteo): proper error handling
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			return nil, c.apiError(resp, endpointURL)
+		}
+
+		var page struct {
+			Items         []EnvironmentVariable `json:"items"`
+			NextPageToken string                `json:"next_page_token"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			// TODO(matteo): proper error handling
+			return nil, err
+		}
+
+		envVars = append(envVars, page.Items...)
+
+		if page.NextPageToken == "" {
+			return envVars, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+const (
+	followEndpoint   = "follow"
+	unfollowEndpoint = "unfollow"
+	settingsEndpoint = "settings"
+)
+
+// ProjectSettings holds the build policy flags of a CircleCI project, as
+// returned and accepted by the settings endpoint.
+type ProjectSettings struct {
+	BuildForkPRs     bool            `json:"build_fork_prs"`
+	OSS              bool            `json:"oss"`
+	AutocancelBuilds bool            `json:"autocancel_builds"`
+	BuildPRsOnly     bool            `json:"build_prs_only"`
+	FeatureFlags     map[string]bool `json:"feature_flags,omitempty"`
+}
+
+// FollowProject follows (enables) a project in CircleCI.
+// https://circleci.com/docs/api/#follow-a-new-project
+func (c *Client) FollowProject(projectName string) error {
+	endpointURL := c.buildApiURL(projectName, followEndpoint)
+
+	req, err := http.NewRequest(http.MethodPost, endpointURL, nil)
+	if err != nil {
+		// TODO(matteo): proper error handling
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	c.setAuth(req)
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		// TODO(matteo): proper error handling
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if c.apiVersion == APIVersionV2 {
+			return c.apiError(resp, endpointURL)
+		}
+		return fmt.Errorf("circleci: wrong status code %d following project", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// UnfollowProject unfollows (disables) a project in CircleCI.
+func (c *Client) UnfollowProject(projectName string) error {
+	endpointURL := c.buildApiURL(projectName, unfollowEndpoint)
+
+	req, err := http.NewRequest(http.MethodDelete, endpointURL, nil)
+	if err != nil {
+		// TODO(matteo): proper error handling
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	c.setAuth(req)
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		// TODO(matteo): proper error handling
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if c.apiVersion == APIVersionV2 {
+			return c.apiError(resp, endpointURL)
+		}
+		return fmt.Errorf("circleci: wrong status code %d unfollowing project", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// GetProjectSettings returns the current build policy settings of a project.
+func (c *Client) GetProjectSettings(projectName string) (*ProjectSettings, error) {
+	endpointURL := c.buildApiURL(projectName, settingsEndpoint)
+
+	req, err := http.NewRequest(http.MethodGet, endpointURL, nil)
+	if err != nil {
+		// TODO(matteo): proper error handling
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	c.setAuth(req)
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		// TODO(matteo): proper error handling
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// Always return a structured APIError on 404, regardless of API
+		// version, so resourceCircleCIProjectRead can tell a deleted
+		// project apart from any other failure with errors.As.
+		if c.apiVersion == APIVersionV2 || resp.StatusCode == http.StatusNotFound {
+			return nil, c.apiError(resp, endpointURL)
+		}
+		return nil, fmt.Errorf("circleci: wrong status code %d getting project settings", resp.StatusCode)
+	}
+
+	settings := new(ProjectSettings)
+	if err := json.NewDecoder(resp.Body).Decode(settings); err != nil {
+		// TODO(matteo): proper error handling
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// UpdateProjectSettings updates the build policy settings of a project and
+// returns the settings as stored by CircleCI.
+func (c *Client) UpdateProjectSettings(projectName string, settings *ProjectSettings) (*ProjectSettings, error) {
+	endpointURL := c.buildApiURL(projectName, settingsEndpoint)
+
+	b := new(bytes.Buffer)
+	if err := json.NewEncoder(b).Encode(settings); err != nil {
+		// TODO(matteo): proper error handling
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPut, endpointURL, b)
+	if err != nil {
+		// TODO(matteo): proper error handling
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	c.setAuth(req)
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		// TODO(matteo): proper error handling
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if c.apiVersion == APIVersionV2 {
+			return nil, c.apiError(resp, endpointURL)
+		}
+		return nil, fmt.Errorf("circleci: wrong status code %d updating project settings", resp.StatusCode)
+	}
+
+	updated := new(ProjectSettings)
+	if err := json.NewDecoder(resp.Body).Decode(updated); err != nil {
+		// TODO(matteo): proper error handling
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+type graphqlRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+type graphqlError struct {
+	Message string `json:"message"`
+}
+
+type graphqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []graphqlError  `json:"errors"`
+}
+
+// doGraphQL issues a query or mutation against the CircleCI GraphQL API and,
+// when out is non-nil, decodes the `data` field of the response into it.
+func (c *Client) doGraphQL(query string, variables map[string]interface{}, out interface{}) error {
+	b := new(bytes.Buffer)
+	if err := json.NewEncoder(b).Encode(graphqlRequest{Query: query, Variables: variables}); err != nil {
+		// TODO(matteo): proper error handling
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, graphqlURL, b)
+	if err != nil {
+		// TODO(matteo): proper error handling
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.SetBasicAuth(c.token, "")
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		// TODO(matteo): proper error handling
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("circleci: wrong status code %d calling graphql api", resp.StatusCode)
+	}
+
+	var gr graphqlResponse
+	if err := json.NewDecoder(resp.Body).Decode(&gr); err != nil {
+		// TODO(matteo): proper error handling
+		return err
+	}
+
+	if len(gr.Errors) > 0 {
+		messages := make([]string, len(gr.Errors))
+		for i, e := range gr.Errors {
+			messages[i] = e.Message
+		}
+		return &GraphQLError{Messages: messages}
+	}
+
+	if out != nil && gr.Data != nil {
+		if err := json.Unmarshal(gr.Data, out); err != nil {
+			// TODO(matteo): proper error handling
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreateContext creates a new context owned by the given organization.
+func (c *Client) CreateContext(orgName, vcsType, name string) (*Context, error) {
+	query := `mutation($name: String!, $organizationName: String!, $organizationVcsType: VCSType!) {
+		createContext(name: $name, organizationName: $organizationName, organizationVcsType: $organizationVcsType) {
+			context {
+				id
+				name
+			}
+		}
+	}`
+
+	variables := map[string]interface{}{
+		"name":                name,
+		"organizationName":    orgName,
+		"organizationVcsType": strings.ToUpper(vcsType),
+	}
+
+	var data struct {
+		CreateContext struct {
+			Context Context `json:"context"`
+		} `json:"createContext"`
+	}
+
+	if err := c.doGraphQL(query, variables, &data); err != nil {
+		return nil, err
+	}
+
+	return &data.CreateContext.Context, nil
+}
+
+// DeleteContext deletes a context given its id.
+func (c *Client) DeleteContext(id string) error {
+	query := `mutation($id: ID!) {
+		deleteContext(id: $id) {
+			id
+		}
+	}`
+
+	variables := map[string]interface{}{
+		"id": id,
+	}
+
+	return c.doGraphQL(query, variables, nil)
+}
+
+// ListContexts returns every context owned by the given organization. It is
+// also used to reconstruct a circleci_context resource's state, since the
+// GraphQL API has no single-context lookup by id.
+func (c *Client) ListContexts(orgName, vcsType string) ([]Context, error) {
+	query := `query($name: String!, $vcsType: VCSType!) {
+		organization(name: $name, vcsType: $vcsType) {
+			contexts {
+				edges {
+					node {
+						id
+						name
+					}
+				}
+			}
+		}
+	}`
+
+	variables := map[string]interface{}{
+		"name":    orgName,
+		"vcsType": strings.ToUpper(vcsType),
+	}
+
+	var data struct {
+		Organization struct {
+			Contexts struct {
+				Edges []struct {
+					Node Context `json:"node"`
+				} `json:"edges"`
+			} `json:"contexts"`
+		} `json:"organization"`
+	}
+
+	if err := c.doGraphQL(query, variables, &data); err != nil {
+		return nil, err
+	}
+
+	contexts := make([]Context, len(data.Organization.Contexts.Edges))
+	for i, edge := range data.Organization.Contexts.Edges {
+		contexts[i] = edge.Node
+	}
+
+	return contexts, nil
+}
+
+// StoreEnvironmentVariable creates or updates an environment variable in a
+// context. The value is write-only: the GraphQL API never returns it back.
+func (c *Client) StoreEnvironmentVariable(contextID, name, value string) error {
+	query := `mutation($contextId: ID!, $variable: String!, $value: String!) {
+		storeEnvironmentVariable(contextId: $contextId, variable: $variable, value: $value) {
+			context {
+				id
+			}
+		}
+	}`
+
+	variables := map[string]interface{}{
+		"contextId": contextID,
+		"variable":  name,
+		"value":     value,
+	}
+
+	return c.doGraphQL(query, variables, nil)
+}
+
+// RemoveEnvironmentVariable deletes an environment variable from a context.
+func (c *Client) RemoveEnvironmentVariable(contextID, name string) error {
+	query := `mutation($contextId: ID!, $variable: String!) {
+		removeEnvironmentVariable(contextId: $contextId, variable: $variable) {
+			context {
+				id
+			}
+		}
+	}`
+
+	variables := map[string]interface{}{
+		"contextId": contextID,
+		"variable":  name,
+	}
+
+	return c.doGraphQL(query, variables, nil)
+}
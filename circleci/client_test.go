@@ -2,6 +2,8 @@ package circleci
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -225,3 +227,611 @@ func TestClient_DeleteEnvironmentVariableWrongStatus(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, err.Error(), "circleci: wrong status code 404 deleting environment variable")
 }
+
+func TestClient_CreateContextOK(t *testing.T) {
+	token := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	httpClient := &http.Client{
+		Transport: testingRoundTripper(func(r *http.Request) (*http.Response, error) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, graphqlURL, r.URL.String())
+			assert.Equal(t, "application/json; charset=utf-8", r.Header.Get("Content-Type"))
+
+			username, password, ok := r.BasicAuth()
+			assert.True(t, ok)
+			assert.Equal(t, token, username)
+			assert.Equal(t, "", password)
+
+			var body graphqlRequest
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Contains(t, body.Query, "createContext")
+			assert.Equal(t, "foo", body.Variables["name"])
+			assert.Equal(t, "bar", body.Variables["organizationName"])
+			assert.Equal(t, "GITHUB", body.Variables["organizationVcsType"])
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"data":{"createContext":{"context":{"id":"ctx-1","name":"foo"}}}}`))),
+			}, nil
+		}),
+	}
+
+	client := Client{
+		token:      token,
+		httpClient: httpClient,
+	}
+
+	context, err := client.CreateContext("bar", "github", "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, &Context{ID: "ctx-1", Name: "foo"}, context)
+}
+
+func TestClient_ListContextsOK(t *testing.T) {
+	token := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	httpClient := &http.Client{
+		Transport: testingRoundTripper(func(r *http.Request) (*http.Response, error) {
+			username, password, ok := r.BasicAuth()
+			assert.True(t, ok)
+			assert.Equal(t, token, username)
+			assert.Equal(t, "", password)
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body: ioutil.NopCloser(bytes.NewReader([]byte(`{"data":{"organization":{"contexts":{"edges":[
+					{"node":{"id":"ctx-1","name":"foo"}},
+					{"node":{"id":"ctx-2","name":"bar"}}
+				]}}}}`))),
+			}, nil
+		}),
+	}
+
+	client := Client{
+		token:      token,
+		httpClient: httpClient,
+	}
+
+	contexts, err := client.ListContexts("bar", "github")
+	assert.NoError(t, err)
+	assert.Equal(t, []Context{
+		{ID: "ctx-1", Name: "foo"},
+		{ID: "ctx-2", Name: "bar"},
+	}, contexts)
+}
+
+func TestClient_CreateContextGraphQLError(t *testing.T) {
+	token := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	httpClient := &http.Client{
+		Transport: testingRoundTripper(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"errors":[{"message":"context already exists"}]}`))),
+			}, nil
+		}),
+	}
+
+	client := Client{
+		token:      token,
+		httpClient: httpClient,
+	}
+
+	context, err := client.CreateContext("bar", "github", "foo")
+	assert.Error(t, err)
+	assert.Equal(t, "circleci: graphql errors: context already exists", err.Error())
+	assert.Nil(t, context)
+}
+
+func TestClient_DeleteContextOK(t *testing.T) {
+	token := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	httpClient := &http.Client{
+		Transport: testingRoundTripper(func(r *http.Request) (*http.Response, error) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, graphqlURL, r.URL.String())
+
+			username, password, ok := r.BasicAuth()
+			assert.True(t, ok)
+			assert.Equal(t, token, username)
+			assert.Equal(t, "", password)
+
+			var body graphqlRequest
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Contains(t, body.Query, "deleteContext")
+			assert.Equal(t, "ctx-1", body.Variables["id"])
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"data":{"deleteContext":{"id":"ctx-1"}}}`))),
+			}, nil
+		}),
+	}
+
+	client := Client{
+		token:      token,
+		httpClient: httpClient,
+	}
+
+	err := client.DeleteContext("ctx-1")
+	assert.NoError(t, err)
+}
+
+func TestClient_StoreEnvironmentVariableOK(t *testing.T) {
+	token := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	httpClient := &http.Client{
+		Transport: testingRoundTripper(func(r *http.Request) (*http.Response, error) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, graphqlURL, r.URL.String())
+
+			username, password, ok := r.BasicAuth()
+			assert.True(t, ok)
+			assert.Equal(t, token, username)
+			assert.Equal(t, "", password)
+
+			var body graphqlRequest
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Contains(t, body.Query, "storeEnvironmentVariable")
+			assert.Equal(t, "ctx-1", body.Variables["contextId"])
+			assert.Equal(t, "FOO", body.Variables["variable"])
+			assert.Equal(t, "bar", body.Variables["value"])
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"data":{"storeEnvironmentVariable":{"context":{"id":"ctx-1"}}}}`))),
+			}, nil
+		}),
+	}
+
+	client := Client{
+		token:      token,
+		httpClient: httpClient,
+	}
+
+	err := client.StoreEnvironmentVariable("ctx-1", "FOO", "bar")
+	assert.NoError(t, err)
+}
+
+func TestClient_RemoveEnvironmentVariableOK(t *testing.T) {
+	token := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	httpClient := &http.Client{
+		Transport: testingRoundTripper(func(r *http.Request) (*http.Response, error) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, graphqlURL, r.URL.String())
+
+			username, password, ok := r.BasicAuth()
+			assert.True(t, ok)
+			assert.Equal(t, token, username)
+			assert.Equal(t, "", password)
+
+			var body graphqlRequest
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Contains(t, body.Query, "removeEnvironmentVariable")
+			assert.Equal(t, "ctx-1", body.Variables["contextId"])
+			assert.Equal(t, "FOO", body.Variables["variable"])
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"data":{"removeEnvironmentVariable":{"context":{"id":"ctx-1"}}}}`))),
+			}, nil
+		}),
+	}
+
+	client := Client{
+		token:      token,
+		httpClient: httpClient,
+	}
+
+	err := client.RemoveEnvironmentVariable("ctx-1", "FOO")
+	assert.NoError(t, err)
+}
+
+func TestClient_buildApiURLV2(t *testing.T) {
+	client, err := NewClient("something", "github", "circleci", WithAPIVersion(APIVersionV2))
+	assert.NoError(t, err)
+
+	actual := client.buildApiURL("project1", "test1")
+	assert.Equal(t, "https://circleci.com/api/v2/project/gh/circleci/project1/test1", actual)
+}
+
+func TestClient_AddEnvironmentVariableV2OK(t *testing.T) {
+	token := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	httpClient := &http.Client{
+		Transport: testingRoundTripper(func(r *http.Request) (*http.Response, error) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, token, r.Header.Get("Circle-Token"))
+			_, _, ok := r.BasicAuth()
+			assert.False(t, ok)
+
+			return &http.Response{
+				StatusCode: http.StatusCreated,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(""))),
+			}, nil
+		}),
+	}
+
+	client := Client{
+		token:        token,
+		vcsType:      "github",
+		organization: "foo",
+		baseURL:      defaultBaseURLV2,
+		apiVersion:   APIVersionV2,
+		httpClient:   httpClient,
+	}
+
+	err := client.AddEnvironmentVariable("bar", "key", "value")
+	assert.NoError(t, err)
+}
+
+func TestClient_GetEnvironmentVariableV2APIError(t *testing.T) {
+	token := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	httpClient := &http.Client{
+		Transport: testingRoundTripper(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusNotFound,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"message":"env var not found"}`))),
+			}, nil
+		}),
+	}
+
+	client := Client{
+		token:        token,
+		vcsType:      "github",
+		organization: "foo",
+		baseURL:      defaultBaseURLV2,
+		apiVersion:   APIVersionV2,
+		httpClient:   httpClient,
+	}
+
+	envVar, err := client.GetEnvironmentVariable("bar", "key")
+	assert.Nil(t, envVar)
+	assert.Error(t, err)
+
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "env var not found", apiErr.Message)
+}
+
+func TestClient_ListEnvironmentVariablesPaginated(t *testing.T) {
+	token := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	var calls int
+	httpClient := &http.Client{
+		Transport: testingRoundTripper(func(r *http.Request) (*http.Response, error) {
+			calls++
+
+			if r.URL.Query().Get("page-token") == "" {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"items":[{"name":"ONE","value":"xxxx"}],"next_page_token":"next"}`))),
+				}, nil
+			}
+
+			assert.Equal(t, "next", r.URL.Query().Get("page-token"))
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"items":[{"name":"TWO","value":"yyyy"}],"next_page_token":""}`))),
+			}, nil
+		}),
+	}
+
+	client := Client{
+		token:        token,
+		vcsType:      "github",
+		organization: "foo",
+		baseURL:      defaultBaseURLV2,
+		apiVersion:   APIVersionV2,
+		httpClient:   httpClient,
+	}
+
+	envVars, err := client.ListEnvironmentVariables("bar")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, []EnvironmentVariable{
+		{Name: "ONE", Value: "xxxx"},
+		{Name: "TWO", Value: "yyyy"},
+	}, envVars)
+}
+
+func TestClient_ListEnvironmentVariablesRequiresV2(t *testing.T) {
+	httpClient := &http.Client{
+		Transport: testingRoundTripper(func(r *http.Request) (*http.Response, error) {
+			t.Fatal("no request should be made for a v1.1 client")
+			return nil, nil
+		}),
+	}
+
+	client := Client{
+		token:        "something",
+		vcsType:      "github",
+		organization: "foo",
+		baseURL:      defaultBaseURLV1,
+		apiVersion:   APIVersionV1,
+		httpClient:   httpClient,
+	}
+
+	envVars, err := client.ListEnvironmentVariables("bar")
+	assert.Error(t, err)
+	assert.Equal(t, "circleci: ListEnvironmentVariables requires a client configured with WithAPIVersion(APIVersionV2)", err.Error())
+	assert.Nil(t, envVars)
+}
+
+func TestClient_doRequestRetriesOn429ThenSucceeds(t *testing.T) {
+	token := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	var calls int
+	httpClient := &http.Client{
+		Transport: testingRoundTripper(func(r *http.Request) (*http.Response, error) {
+			calls++
+			if calls == 1 {
+				return &http.Response{
+					StatusCode: http.StatusTooManyRequests,
+					Header:     http.Header{"Retry-After": []string{"0"}},
+					Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+				}, nil
+			}
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte("{\"name\":\"key\",\"value\":\"value\"}\n"))),
+			}, nil
+		}),
+	}
+
+	client := Client{
+		token:        token,
+		vcsType:      "github",
+		organization: "foo",
+		httpClient:   httpClient,
+		maxRetries:   defaultMaxRetries,
+	}
+
+	envVar, err := client.GetEnvironmentVariable("bar", "key")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, &EnvironmentVariable{Name: "key", Value: "value"}, envVar)
+}
+
+func TestClient_FollowProjectOK(t *testing.T) {
+	token := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	httpClient := &http.Client{
+		Transport: testingRoundTripper(func(r *http.Request) (*http.Response, error) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "https://circleci.com/api/v1.1/project/github/foo/bar/follow", r.URL.String())
+
+			username, password, ok := r.BasicAuth()
+			assert.True(t, ok)
+			assert.Equal(t, token, username)
+			assert.Equal(t, "", password)
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"following":true}`))),
+			}, nil
+		}),
+	}
+
+	client := Client{
+		token:        token,
+		vcsType:      "github",
+		organization: "foo",
+		baseURL:      defaultBaseURLV1,
+		httpClient:   httpClient,
+	}
+
+	err := client.FollowProject("bar")
+	assert.NoError(t, err)
+}
+
+func TestClient_FollowProjectWrongStatus(t *testing.T) {
+	token := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	httpClient := &http.Client{
+		Transport: testingRoundTripper(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusBadRequest,
+				Body:       ioutil.NopCloser(nil),
+			}, nil
+		}),
+	}
+
+	client := Client{
+		token:        token,
+		vcsType:      "github",
+		organization: "foo",
+		baseURL:      defaultBaseURLV1,
+		httpClient:   httpClient,
+	}
+
+	err := client.FollowProject("bar")
+	assert.Error(t, err)
+	assert.Equal(t, "circleci: wrong status code 400 following project", err.Error())
+}
+
+func TestClient_UnfollowProjectOK(t *testing.T) {
+	token := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	httpClient := &http.Client{
+		Transport: testingRoundTripper(func(r *http.Request) (*http.Response, error) {
+			assert.Equal(t, http.MethodDelete, r.Method)
+			assert.Equal(t, "https://circleci.com/api/v1.1/project/github/foo/bar/unfollow", r.URL.String())
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"following":false}`))),
+			}, nil
+		}),
+	}
+
+	client := Client{
+		token:        token,
+		vcsType:      "github",
+		organization: "foo",
+		baseURL:      defaultBaseURLV1,
+		httpClient:   httpClient,
+	}
+
+	err := client.UnfollowProject("bar")
+	assert.NoError(t, err)
+}
+
+func TestClient_UnfollowProjectWrongStatus(t *testing.T) {
+	token := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	httpClient := &http.Client{
+		Transport: testingRoundTripper(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusBadRequest,
+				Body:       ioutil.NopCloser(nil),
+			}, nil
+		}),
+	}
+
+	client := Client{
+		token:        token,
+		vcsType:      "github",
+		organization: "foo",
+		baseURL:      defaultBaseURLV1,
+		httpClient:   httpClient,
+	}
+
+	err := client.UnfollowProject("bar")
+	assert.Error(t, err)
+	assert.Equal(t, "circleci: wrong status code 400 unfollowing project", err.Error())
+}
+
+func TestClient_GetProjectSettingsOK(t *testing.T) {
+	token := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	httpClient := &http.Client{
+		Transport: testingRoundTripper(func(r *http.Request) (*http.Response, error) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			assert.Equal(t, "https://circleci.com/api/v1.1/project/github/foo/bar/settings", r.URL.String())
+
+			username, password, ok := r.BasicAuth()
+			assert.True(t, ok)
+			assert.Equal(t, token, username)
+			assert.Equal(t, "", password)
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"build_fork_prs":true,"oss":false,"autocancel_builds":true,"build_prs_only":false,"feature_flags":{"osx":true}}`))),
+			}, nil
+		}),
+	}
+
+	client := Client{
+		token:        token,
+		vcsType:      "github",
+		organization: "foo",
+		baseURL:      defaultBaseURLV1,
+		httpClient:   httpClient,
+	}
+
+	settings, err := client.GetProjectSettings("bar")
+	assert.NoError(t, err)
+	assert.Equal(t, &ProjectSettings{
+		BuildForkPRs:     true,
+		AutocancelBuilds: true,
+		FeatureFlags:     map[string]bool{"osx": true},
+	}, settings)
+}
+
+func TestClient_UpdateProjectSettingsOK(t *testing.T) {
+	token := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	httpClient := &http.Client{
+		Transport: testingRoundTripper(func(r *http.Request) (*http.Response, error) {
+			assert.Equal(t, http.MethodPut, r.Method)
+			assert.Equal(t, "https://circleci.com/api/v1.1/project/github/foo/bar/settings", r.URL.String())
+
+			bodyContent, err := ioutil.ReadAll(r.Body)
+			assert.NoError(t, err)
+			assert.JSONEq(t, `{"build_fork_prs":true,"oss":false,"autocancel_builds":true,"build_prs_only":false}`, string(bodyContent))
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"build_fork_prs":true,"oss":false,"autocancel_builds":true,"build_prs_only":false}`))),
+			}, nil
+		}),
+	}
+
+	client := Client{
+		token:        token,
+		vcsType:      "github",
+		organization: "foo",
+		baseURL:      defaultBaseURLV1,
+		httpClient:   httpClient,
+	}
+
+	settings, err := client.UpdateProjectSettings("bar", &ProjectSettings{
+		BuildForkPRs:     true,
+		AutocancelBuilds: true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &ProjectSettings{
+		BuildForkPRs:     true,
+		AutocancelBuilds: true,
+	}, settings)
+}
+
+func TestClient_GetProjectSettingsWrongStatus(t *testing.T) {
+	token := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	httpClient := &http.Client{
+		Transport: testingRoundTripper(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusBadRequest,
+				Body:       ioutil.NopCloser(nil),
+			}, nil
+		}),
+	}
+
+	client := Client{
+		token:        token,
+		vcsType:      "github",
+		organization: "foo",
+		baseURL:      defaultBaseURLV1,
+		httpClient:   httpClient,
+	}
+
+	settings, err := client.GetProjectSettings("bar")
+	assert.Error(t, err)
+	assert.Equal(t, "circleci: wrong status code 400 getting project settings", err.Error())
+	assert.Nil(t, settings)
+}
+
+func TestClient_GetProjectSettingsNotFoundIsAPIErrorOnV1(t *testing.T) {
+	token := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	httpClient := &http.Client{
+		Transport: testingRoundTripper(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusNotFound,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"message":"project not found"}`))),
+			}, nil
+		}),
+	}
+
+	client := Client{
+		token:        token,
+		vcsType:      "github",
+		organization: "foo",
+		baseURL:      defaultBaseURLV1,
+		httpClient:   httpClient,
+	}
+
+	settings, err := client.GetProjectSettings("bar")
+	assert.Nil(t, settings)
+	assert.Error(t, err)
+
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "project not found", apiErr.Message)
+}
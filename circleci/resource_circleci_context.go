@@ -0,0 +1,88 @@
+package circleci
+
+import (
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCircleCIContext() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCircleCIContextCreate,
+		Read:   resourceCircleCIContextRead,
+		Delete: resourceCircleCIContextDelete,
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(5 * time.Minute),
+			Delete: schema.DefaultTimeout(5 * time.Minute),
+		},
+		Schema: map[string]*schema.Schema{
+			"owner": {
+				Description: "The name of the organization owning the context",
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+			},
+			"name": {
+				Description: "The name of the context",
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+			},
+		},
+	}
+}
+
+func resourceCircleCIContextCreate(d *schema.ResourceData, m interface{}) error {
+	client := m.(*Client)
+
+	owner := d.Get("owner").(string)
+	name := d.Get("name").(string)
+
+	context, err := client.CreateContext(owner, client.vcsType, name)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(context.ID)
+
+	return resourceCircleCIContextRead(d, m)
+}
+
+func resourceCircleCIContextRead(d *schema.ResourceData, m interface{}) error {
+	client := m.(*Client)
+
+	owner := d.Get("owner").(string)
+
+	// The GraphQL API has no single-context lookup by id, so we list every
+	// context of the organization and find ours.
+	contexts, err := client.ListContexts(owner, client.vcsType)
+	if err != nil {
+		return err
+	}
+
+	for _, context := range contexts {
+		if context.ID == d.Id() {
+			return d.Set("name", context.Name)
+		}
+	}
+
+	// the context is gone
+	d.SetId("")
+
+	return nil
+}
+
+func resourceCircleCIContextDelete(d *schema.ResourceData, m interface{}) error {
+	client := m.(*Client)
+
+	if err := client.DeleteContext(d.Id()); err != nil {
+		return err
+	}
+
+	d.SetId("")
+
+	return nil
+}
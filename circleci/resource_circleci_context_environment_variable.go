@@ -0,0 +1,86 @@
+package circleci
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCircleCIContextEnvironmentVariable() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCircleCIContextEnvironmentVariableCreate,
+		Read:   resourceCircleCIContextEnvironmentVariableRead,
+		Delete: resourceCircleCIContextEnvironmentVariableDelete,
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(5 * time.Minute),
+			Delete: schema.DefaultTimeout(5 * time.Minute),
+		},
+		Schema: map[string]*schema.Schema{
+			"context_id": {
+				Description: "The id of the context to create the variable in",
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+			},
+			"name": {
+				Description:  "The name of the environment variable",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateName,
+			},
+			"value": {
+				Description: "The value of the environment variable",
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Sensitive:   true,
+				StateFunc: func(value interface{}) string {
+					/* To avoid storing the value of the environment variable in the state
+					but still be able to know when the value change, we store a hash of the value.
+					*/
+					return hashString(value.(string))
+				},
+			},
+		},
+	}
+}
+
+func resourceCircleCIContextEnvironmentVariableCreate(d *schema.ResourceData, m interface{}) error {
+	client := m.(*Client)
+
+	contextID := d.Get("context_id").(string)
+	name := d.Get("name").(string)
+	value := d.Get("value").(string)
+
+	if err := client.StoreEnvironmentVariable(contextID, name, value); err != nil {
+		return err
+	}
+
+	d.SetId(fmt.Sprintf("%s/%s", contextID, name))
+
+	return resourceCircleCIContextEnvironmentVariableRead(d, m)
+}
+
+func resourceCircleCIContextEnvironmentVariableRead(d *schema.ResourceData, m interface{}) error {
+	// The GraphQL API never returns the value of a context environment
+	// variable back, and there is no query to fetch one by name either, so
+	// there is nothing to reconcile beyond what Create already set.
+	return nil
+}
+
+func resourceCircleCIContextEnvironmentVariableDelete(d *schema.ResourceData, m interface{}) error {
+	client := m.(*Client)
+
+	contextID := d.Get("context_id").(string)
+	name := d.Get("name").(string)
+
+	if err := client.RemoveEnvironmentVariable(contextID, name); err != nil {
+		return err
+	}
+
+	d.SetId("")
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package circleci
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCircleCIProject() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCircleCIProjectCreate,
+		Read:   resourceCircleCIProjectRead,
+		Update: resourceCircleCIProjectUpdate,
+		Delete: resourceCircleCIProjectDelete,
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(5 * time.Minute),
+			Update: schema.DefaultTimeout(5 * time.Minute),
+			Delete: schema.DefaultTimeout(5 * time.Minute),
+		},
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Description: "The name of the CircleCI project to follow",
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+			},
+			"settings": {
+				Description: "The build policy settings of the project",
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"build_fork_prs": {
+							Description: "Whether to build forked pull requests",
+							Type:        schema.TypeBool,
+							Optional:    true,
+						},
+						"oss": {
+							Description: "Whether to enable free and open source public build support",
+							Type:        schema.TypeBool,
+							Optional:    true,
+						},
+						"autocancel_builds": {
+							Description: "Whether to auto-cancel redundant builds",
+							Type:        schema.TypeBool,
+							Optional:    true,
+						},
+						"build_prs_only": {
+							Description: "Whether to only build pull requests",
+							Type:        schema.TypeBool,
+							Optional:    true,
+						},
+						"feature_flags": {
+							Description: "Additional feature flags to set on the project",
+							Type:        schema.TypeMap,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeBool},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// projectNameFromID extracts the project name out of either a plain name or
+// a "vcs/org/name" import slug.
+func projectNameFromID(id string) string {
+	parts := strings.Split(id, "/")
+	return parts[len(parts)-1]
+}
+
+func expandProjectSettings(raw []interface{}) *ProjectSettings {
+	if len(raw) == 0 || raw[0] == nil {
+		return nil
+	}
+
+	m := raw[0].(map[string]interface{})
+
+	featureFlags := make(map[string]bool)
+	for k, v := range m["feature_flags"].(map[string]interface{}) {
+		featureFlags[k] = v.(bool)
+	}
+
+	return &ProjectSettings{
+		BuildForkPRs:     m["build_fork_prs"].(bool),
+		OSS:              m["oss"].(bool),
+		AutocancelBuilds: m["autocancel_builds"].(bool),
+		BuildPRsOnly:     m["build_prs_only"].(bool),
+		FeatureFlags:     featureFlags,
+	}
+}
+
+func flattenProjectSettings(settings *ProjectSettings) []interface{} {
+	if settings == nil {
+		return nil
+	}
+
+	featureFlags := make(map[string]interface{}, len(settings.FeatureFlags))
+	for k, v := range settings.FeatureFlags {
+		featureFlags[k] = v
+	}
+
+	return []interface{}{
+		map[string]interface{}{
+			"build_fork_prs":    settings.BuildForkPRs,
+			"oss":               settings.OSS,
+			"autocancel_builds": settings.AutocancelBuilds,
+			"build_prs_only":    settings.BuildPRsOnly,
+			"feature_flags":     featureFlags,
+		},
+	}
+}
+
+func resourceCircleCIProjectCreate(d *schema.ResourceData, m interface{}) error {
+	client := m.(*Client)
+
+	name := d.Get("name").(string)
+
+	if err := client.FollowProject(name); err != nil {
+		return err
+	}
+
+	if settings := expandProjectSettings(d.Get("settings").([]interface{})); settings != nil {
+		if _, err := client.UpdateProjectSettings(name, settings); err != nil {
+			return err
+		}
+	}
+
+	d.SetId(strings.Join([]string{client.vcsType, client.organization, name}, "/"))
+
+	return resourceCircleCIProjectRead(d, m)
+}
+
+func resourceCircleCIProjectRead(d *schema.ResourceData, m interface{}) error {
+	client := m.(*Client)
+
+	name := projectNameFromID(d.Id())
+	if err := d.Set("name", name); err != nil {
+		return err
+	}
+
+	settings, err := client.GetProjectSettings(name)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			// the project was unfollowed outside of Terraform
+			d.SetId("")
+			return nil
+		}
+		return err
+	}
+
+	return d.Set("settings", flattenProjectSettings(settings))
+}
+
+func resourceCircleCIProjectUpdate(d *schema.ResourceData, m interface{}) error {
+	client := m.(*Client)
+
+	name := d.Get("name").(string)
+
+	if d.HasChange("settings") {
+		settings := expandProjectSettings(d.Get("settings").([]interface{}))
+		if settings == nil {
+			settings = &ProjectSettings{}
+		}
+
+		if _, err := client.UpdateProjectSettings(name, settings); err != nil {
+			return err
+		}
+	}
+
+	return resourceCircleCIProjectRead(d, m)
+}
+
+func resourceCircleCIProjectDelete(d *schema.ResourceData, m interface{}) error {
+	client := m.(*Client)
+
+	name := d.Get("name").(string)
+
+	if err := client.UnfollowProject(name); err != nil {
+		return err
+	}
+
+	d.SetId("")
+
+	return nil
+}